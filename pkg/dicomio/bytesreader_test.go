@@ -0,0 +1,62 @@
+package dicomio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestNewBytesReader_DecodesFixedWidthIntsWithoutCopying(t *testing.T) {
+	data := []byte{0x01, 0x02, 0x03, 0x04, 0xFF, 0xFF}
+	r, err := NewBytesReader(data, binary.LittleEndian, int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewBytesReader: %v", err)
+	}
+	if got, err := r.ReadUInt32(); err != nil || got != 0x04030201 {
+		t.Errorf("ReadUInt32() = (%#x, %v), want (0x04030201, nil)", got, err)
+	}
+	if got, err := r.ReadInt16(); err != nil || got != -1 {
+		t.Errorf("ReadInt16() = (%d, %v), want (-1, nil)", got, err)
+	}
+}
+
+func TestReadBytes_AliasesBackingArrayForSliceReader(t *testing.T) {
+	data := []byte("OB payload bytes")
+	r, err := NewBytesReader(data, binary.LittleEndian, int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewBytesReader: %v", err)
+	}
+	got, err := r.ReadBytes(uint32(len(data)))
+	if err != nil {
+		t.Fatalf("ReadBytes: %v", err)
+	}
+	if &got[0] != &data[0] {
+		t.Errorf("ReadBytes() returned a copy, want a view into the original backing array")
+	}
+}
+
+func TestReadBytes_CopiesForStreamReader(t *testing.T) {
+	data := []byte("stream payload")
+	r, err := NewReader(&onlyReader{bytes.NewReader(data)}, binary.LittleEndian, int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	got, err := r.ReadBytes(uint32(len(data)))
+	if err != nil {
+		t.Fatalf("ReadBytes: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("ReadBytes() = %q, want %q", got, data)
+	}
+}
+
+func TestNewReader_DetectsBytesReaderAndUsesSlicePath(t *testing.T) {
+	data := []byte{0xAA, 0xBB}
+	r, err := NewReader(bytes.NewReader(data), binary.BigEndian, int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if got, err := r.ReadUInt16(); err != nil || got != 0xAABB {
+		t.Errorf("ReadUInt16() = (%#x, %v), want (0xaabb, nil)", got, err)
+	}
+}
@@ -0,0 +1,281 @@
+package dicomio
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// CharsetDecoder decodes bytes encoded in a single DICOM-defined character set into a Go string. A
+// CharsetDecoder normally sees only the bytes belonging to the G0/G1 set it was registered for; escape
+// sequences that switch between sets are consumed by Reader.ReadString before dispatching to a decoder.
+// The exception is the charsets in statefulEscapeCharsets, whose decoder is handed the escape sequences
+// along with the rest of the string.
+type CharsetDecoder interface {
+	// Decode converts data, encoded in this character set, to a UTF-8 string. Invalid byte sequences are
+	// replaced with utf8.RuneError ('�') rather than returning an error, matching the lenient decoding
+	// PN/LO/LT/SH/ST/UT parsing needs when a file misdeclares its SpecificCharacterSet.
+	Decode(data []byte) (string, error)
+}
+
+// CharsetFactory constructs a new CharsetDecoder. A factory is called each time ReadString starts decoding
+// a string (or PN component) in its character set, and at each escape sequence that switches to it, so a
+// CharsetDecoder may keep per-string state (e.g. shift state) if it needs to.
+type CharsetFactory func() CharsetDecoder
+
+var charsetRegistry = map[string]CharsetFactory{}
+
+// RegisterCharset makes a CharsetDecoder factory available under the given DICOM Defined Term (the value
+// that appears in SpecificCharacterSet (0008,0005), e.g. "ISO_IR 100" or "GB18030"). Registering under a
+// name that's already registered replaces the existing factory.
+func RegisterCharset(name string, factory CharsetFactory) {
+	charsetRegistry[name] = factory
+}
+
+func init() {
+	RegisterCharset("", func() CharsetDecoder { return asciiDecoder{} })
+	RegisterCharset("ISO_IR 6", func() CharsetDecoder { return asciiDecoder{} })
+	RegisterCharset("ISO 2022 IR 6", func() CharsetDecoder { return asciiDecoder{} })
+	RegisterCharset("ISO_IR 192", func() CharsetDecoder { return asciiDecoder{} })
+	RegisterCharset("UTF-8", func() CharsetDecoder { return asciiDecoder{} })
+
+	registerXTextCharset("ISO_IR 100", charmap.ISO8859_1)
+	registerXTextCharset("ISO 2022 IR 100", charmap.ISO8859_1)
+	registerXTextCharset("ISO_IR 144", charmap.ISO8859_5)
+	registerXTextCharset("ISO 2022 IR 144", charmap.ISO8859_5)
+	registerXTextCharset("ISO_IR 127", charmap.ISO8859_6)
+	registerXTextCharset("ISO_IR 126", charmap.ISO8859_7)
+	registerXTextCharset("ISO_IR 138", charmap.ISO8859_8)
+	registerXTextCharset("ISO_IR 148", charmap.ISO8859_9)
+
+	registerXTextCharset("ISO_IR 13", japanese.ShiftJIS)
+	registerXTextCharset("ISO 2022 IR 13", japanese.ShiftJIS)
+	registerXTextCharset("ISO 2022 IR 87", japanese.ISO2022JP)
+	registerXTextCharset("ISO 2022 IR 159", japanese.ISO2022JP)
+	registerXTextCharset("ISO 2022 IR 149", korean.EUCKR)
+	registerXTextCharset("GB18030", simplifiedchinese.GB18030)
+	registerXTextCharset("GBK", simplifiedchinese.GBK)
+}
+
+// registerXTextCharset registers a CharsetDecoder that wraps a golang.org/x/text/encoding.Encoding. Its
+// Decoder already substitutes the Unicode replacement rune for byte sequences that don't map to a valid
+// code point, rather than failing the whole string.
+func registerXTextCharset(name string, enc encoding.Encoding) {
+	RegisterCharset(name, func() CharsetDecoder {
+		return xtextDecoder{dec: enc.NewDecoder()}
+	})
+}
+
+// asciiDecoder is used for the default repertoire (ISO_IR 6) and for UTF-8/ISO_IR 192. It takes the bytes
+// as-is, replacing each run of invalid UTF-8 with utf8.RuneError.
+type asciiDecoder struct{}
+
+func (asciiDecoder) Decode(data []byte) (string, error) {
+	return strings.ToValidUTF8(string(data), string(utf8.RuneError)), nil
+}
+
+type xtextDecoder struct {
+	dec *encoding.Decoder
+}
+
+func (d xtextDecoder) Decode(data []byte) (string, error) {
+	out, err := d.dec.Bytes(data)
+	if err != nil {
+		return "", fmt.Errorf("dicomio: decoding character set: %v", err)
+	}
+	return string(out), nil
+}
+
+// CharsetEncoder encodes a Go string into bytes in a single DICOM-defined character set. It is the
+// write-side counterpart to CharsetDecoder, used by Writer.WriteString.
+type CharsetEncoder interface {
+	// Encode converts s to its byte representation in this character set. In the golang.org/x/text-backed
+	// encoders registered here, runes that have no representation in the target charset are replaced with
+	// the encoding's substitute byte (ASCII SUB, 0x1A) rather than returning an error, mirroring
+	// CharsetDecoder's lenient handling on the read side. The default (ISO_IR 6) and UTF-8 encoders write
+	// s's UTF-8 bytes unchanged.
+	Encode(s string) ([]byte, error)
+}
+
+// CharsetEncoderFactory constructs a new CharsetEncoder. A factory is called on every WriteString while
+// its character set is active, so a CharsetEncoder may keep per-string state if it needs to.
+type CharsetEncoderFactory func() CharsetEncoder
+
+var charsetEncoderRegistry = map[string]CharsetEncoderFactory{}
+
+// RegisterCharsetEncoder makes a CharsetEncoder factory available under the given DICOM Defined Term. It
+// mirrors RegisterCharset for the write side; registering under a name that's already registered replaces
+// the existing factory.
+func RegisterCharsetEncoder(name string, factory CharsetEncoderFactory) {
+	charsetEncoderRegistry[name] = factory
+}
+
+func init() {
+	RegisterCharsetEncoder("", func() CharsetEncoder { return asciiEncoder{} })
+	RegisterCharsetEncoder("ISO_IR 6", func() CharsetEncoder { return asciiEncoder{} })
+	RegisterCharsetEncoder("ISO 2022 IR 6", func() CharsetEncoder { return asciiEncoder{} })
+	RegisterCharsetEncoder("ISO_IR 192", func() CharsetEncoder { return asciiEncoder{} })
+	RegisterCharsetEncoder("UTF-8", func() CharsetEncoder { return asciiEncoder{} })
+
+	registerXTextCharsetEncoder("ISO_IR 100", charmap.ISO8859_1)
+	registerXTextCharsetEncoder("ISO 2022 IR 100", charmap.ISO8859_1)
+	registerXTextCharsetEncoder("ISO_IR 144", charmap.ISO8859_5)
+	registerXTextCharsetEncoder("ISO 2022 IR 144", charmap.ISO8859_5)
+	registerXTextCharsetEncoder("ISO_IR 127", charmap.ISO8859_6)
+	registerXTextCharsetEncoder("ISO_IR 126", charmap.ISO8859_7)
+	registerXTextCharsetEncoder("ISO_IR 138", charmap.ISO8859_8)
+	registerXTextCharsetEncoder("ISO_IR 148", charmap.ISO8859_9)
+
+	registerXTextCharsetEncoder("ISO_IR 13", japanese.ShiftJIS)
+	registerXTextCharsetEncoder("ISO 2022 IR 13", japanese.ShiftJIS)
+	registerXTextCharsetEncoder("ISO 2022 IR 87", japanese.ISO2022JP)
+	registerXTextCharsetEncoder("ISO 2022 IR 159", japanese.ISO2022JP)
+	registerXTextCharsetEncoder("ISO 2022 IR 149", korean.EUCKR)
+	registerXTextCharsetEncoder("GB18030", simplifiedchinese.GB18030)
+	registerXTextCharsetEncoder("GBK", simplifiedchinese.GBK)
+}
+
+// registerXTextCharsetEncoder registers a CharsetEncoder that wraps a golang.org/x/text/encoding.Encoding,
+// using encoding.ReplaceUnsupported so a rune with no representation in the target charset becomes the
+// encoding's substitute byte instead of failing the whole string.
+func registerXTextCharsetEncoder(name string, enc encoding.Encoding) {
+	RegisterCharsetEncoder(name, func() CharsetEncoder {
+		return xtextEncoder{enc: encoding.ReplaceUnsupported(enc.NewEncoder())}
+	})
+}
+
+// asciiEncoder is used for the default repertoire (ISO_IR 6) and for UTF-8/ISO_IR 192, both of which take
+// a Go string's UTF-8 bytes as-is.
+type asciiEncoder struct{}
+
+func (asciiEncoder) Encode(s string) ([]byte, error) { return []byte(s), nil }
+
+type xtextEncoder struct {
+	enc *encoding.Encoder
+}
+
+func (e xtextEncoder) Encode(s string) ([]byte, error) {
+	out, err := e.enc.Bytes([]byte(s))
+	if err != nil {
+		return nil, fmt.Errorf("dicomio: encoding character set: %v", err)
+	}
+	return out, nil
+}
+
+// encoderFor looks up the registered CharsetEncoder factory for name, falling back to the default
+// (ISO_IR 6 / ASCII) repertoire if name isn't recognized, mirroring decoderFor's fallback.
+func encoderFor(name string) CharsetEncoder {
+	factory, ok := charsetEncoderRegistry[name]
+	if !ok {
+		factory = charsetEncoderRegistry[""]
+	}
+	return factory()
+}
+
+// escapeDesignation describes what a DICOM code-extension escape sequence (ISO 2022) switches the active
+// decoder to. See PS3.5 Section 6.1.2.5.
+type escapeDesignation struct {
+	charset string // Defined Term to look up in charsetRegistry
+}
+
+// escapeSequences maps the bytes following ESC (0x1b) to the character set they designate, for the code
+// extensions DICOM permits in PN/LO/LT/SH/ST/UT values.
+var escapeSequences = map[string]escapeDesignation{
+	"(B":  {charset: "ISO_IR 6"},        // ASCII, G0
+	"(J":  {charset: "ISO_IR 13"},       // JIS X 0201 Romaji, G0
+	".A":  {charset: "ISO_IR 100"},      // Latin-1 via G2, not common but accepted
+	"$B":  {charset: "ISO 2022 IR 87"},  // JIS X 0208, multi-byte G0
+	"$@":  {charset: "ISO 2022 IR 87"},  // older JIS X 0208 designation
+	"$(D": {charset: "ISO 2022 IR 159"}, // JIS X 0212, multi-byte G0
+	"$)C": {charset: "ISO 2022 IR 149"}, // KS X 1001, multi-byte G1
+	"$)A": {charset: "GB18030"},         // GB 2312, multi-byte G1
+}
+
+// decoderFor looks up the registered CharsetDecoder factory for name, falling back to the default
+// (ISO_IR 6 / ASCII) repertoire if name isn't recognized so that an unsupported or garbled
+// SpecificCharacterSet value degrades gracefully instead of losing the element.
+func decoderFor(name string) CharsetDecoder {
+	factory, ok := charsetRegistry[name]
+	if !ok {
+		factory = charsetRegistry[""]
+	}
+	return factory()
+}
+
+// statefulEscapeCharsets identifies the Defined Terms whose CharsetDecoder manages ISO 2022 G0 shift
+// state from the escape sequences it sees itself (golang.org/x/text's japanese.ISO2022JP, for JIS X
+// 0208/0212), unlike every other registered charset here, which expects escapes stripped before it ever
+// sees a byte. Handing such a decoder only the body between escapes makes it treat double-byte JIS bytes
+// as plain ASCII, since it never sees the ESC that would tell it to switch out of that state.
+var statefulEscapeCharsets = map[string]bool{
+	"ISO 2022 IR 87":  true,
+	"ISO 2022 IR 159": true,
+}
+
+// decodeWithEscapes decodes data that may contain ISO 2022 escape sequences switching between the
+// character sets in escapeSequences mid-string. initial is used until the first escape sequence is seen.
+func decodeWithEscapes(data []byte, initial string) (string, error) {
+	var out bytes.Buffer
+	decoder := decoderFor(initial)
+	start := 0
+	for i := 0; i < len(data); i++ {
+		if data[i] != 0x1b { // ESC
+			continue
+		}
+		if i > start {
+			s, err := decoder.Decode(data[start:i])
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(s)
+		}
+		seq, consumed := matchEscapeSequence(data[i+1:])
+		if seq.charset != "" && statefulEscapeCharsets[seq.charset] {
+			// Hand the matched escape sequence back to the decoder, along with everything remaining in
+			// data, instead of stripping it: the ISO2022JP decoder needs to see every escape itself to
+			// track its own shift state, including any further switches (back to ASCII via ESC ( B,
+			// between JIS X 0208 and 0212, ...) later in this same run.
+			s, err := decoderFor(seq.charset).Decode(data[i:])
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(s)
+			return out.String(), nil
+		}
+		if seq.charset != "" {
+			decoder = decoderFor(seq.charset)
+		}
+		i += consumed
+		start = i + 1
+	}
+	if start < len(data) {
+		s, err := decoder.Decode(data[start:])
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(s)
+	}
+	return out.String(), nil
+}
+
+// matchEscapeSequence finds the longest registered escape sequence that's a prefix of data, returning the
+// designation it selects and the number of bytes the sequence (excluding the leading ESC) occupies. It
+// returns a zero-valued escapeDesignation if no known sequence matches.
+func matchEscapeSequence(data []byte) (escapeDesignation, int) {
+	for length := 3; length >= 1; length-- {
+		if length > len(data) {
+			continue
+		}
+		if d, ok := escapeSequences[string(data[:length])]; ok {
+			return d, length
+		}
+	}
+	return escapeDesignation{}, 0
+}
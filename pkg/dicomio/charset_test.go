@@ -0,0 +1,174 @@
+package dicomio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestSetCharacterSet_RejectsUnknownValue(t *testing.T) {
+	r, err := NewReader(bytes.NewReader(nil), binary.LittleEndian, 0)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if err := r.SetCharacterSet([]string{"NOT_A_REAL_CHARSET"}); err == nil {
+		t.Errorf("SetCharacterSet with an unregistered Defined Term = nil error, want an error")
+	}
+	if err := r.SetCharacterSet([]string{"ISO_IR 100"}); err != nil {
+		t.Errorf("SetCharacterSet(ISO_IR 100) = %v, want nil", err)
+	}
+}
+
+func TestReadString_DecodesPerActiveCharacterSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		charset string
+		data    []byte
+		want    string
+	}{
+		{name: "default ASCII passthrough", charset: "", data: []byte("Smith^John"), want: "Smith^John"},
+		{name: "explicit ISO_IR 6", charset: "ISO_IR 6", data: []byte("Yamada"), want: "Yamada"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(tt.data), binary.LittleEndian, int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("NewReader: %v", err)
+			}
+			if err := r.SetCharacterSet([]string{tt.charset}); err != nil {
+				t.Fatalf("SetCharacterSet(%q): %v", tt.charset, err)
+			}
+			got, err := r.ReadString(uint32(len(tt.data)))
+			if err != nil {
+				t.Fatalf("ReadString: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ReadString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadString_SplitsMultiComponentPNOnEquals(t *testing.T) {
+	data := []byte("Yamada^Tarou=山田^太郎")
+	r, err := NewReader(bytes.NewReader(data), binary.LittleEndian, int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	got, err := r.ReadString(uint32(len(data)))
+	if err != nil {
+		t.Fatalf("ReadString: %v", err)
+	}
+	// With no multi-byte character set configured, the ideographic component round-trips as the raw
+	// UTF-8 bytes it was given (the ASCII decoder passes bytes through untouched).
+	want := "Yamada^Tarou=山田^太郎"
+	if got != want {
+		t.Errorf("ReadString() = %q, want %q", got, want)
+	}
+}
+
+func TestReadString_DecodesRealMultiByteCharsets(t *testing.T) {
+	tests := []struct {
+		name    string
+		charset string
+		data    []byte
+		want    string
+	}{
+		{name: "GB18030", charset: "GB18030", data: []byte{0xc4, 0xe3, 0xba, 0xc3}, want: "你好"},
+		{
+			name:    "Shift-JIS",
+			charset: "ISO_IR 13",
+			data:    []byte{0x82, 0xb1, 0x82, 0xf1, 0x82, 0xc9, 0x82, 0xbf, 0x82, 0xcd},
+			want:    "こんにちは",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(tt.data), binary.LittleEndian, int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("NewReader: %v", err)
+			}
+			if err := r.SetCharacterSet([]string{tt.charset}); err != nil {
+				t.Fatalf("SetCharacterSet(%q): %v", tt.charset, err)
+			}
+			got, err := r.ReadString(uint32(len(tt.data)))
+			if err != nil {
+				t.Fatalf("ReadString: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ReadString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadString_MidStringEscapeSwitchesCharset(t *testing.T) {
+	// "A" in the default (ASCII) repertoire, then ESC $)A switches to GB18030 for the GB18030 encoding of
+	// "你好", then ESC (B switches back to ASCII for a trailing "B".
+	data := []byte{0x41, 0x1b, 0x24, 0x29, 0x41, 0xc4, 0xe3, 0xba, 0xc3, 0x1b, 0x28, 0x42, 0x42}
+	r, err := NewReader(bytes.NewReader(data), binary.LittleEndian, int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	got, err := r.ReadString(uint32(len(data)))
+	if err != nil {
+		t.Fatalf("ReadString: %v", err)
+	}
+	want := "A你好B"
+	if got != want {
+		t.Errorf("ReadString() = %q, want %q", got, want)
+	}
+}
+
+func TestWriteStringReadString_RoundTripsISO2022JPKanji(t *testing.T) {
+	// Regression test: japanese.ISO2022JP manages its own ISO 2022 G0 shift state from the escape
+	// sequences it sees. decodeWithEscapes used to strip the triggering escape sequence and feed it only
+	// the body, which the decoder then read as plain ASCII instead of JIS X 0208.
+	want := "山田^太郎"
+
+	w := NewBufferWriter(binary.LittleEndian)
+	if err := w.SetCharacterSet([]string{"ISO 2022 IR 87"}); err != nil {
+		t.Fatalf("SetCharacterSet: %v", err)
+	}
+	if err := w.WriteString(want); err != nil {
+		t.Fatalf("WriteString: %v", err)
+	}
+	encoded := w.(*writer).Bytes()
+
+	r, err := NewReader(bytes.NewReader(encoded), binary.LittleEndian, int64(len(encoded)))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if err := r.SetCharacterSet([]string{"ISO 2022 IR 87"}); err != nil {
+		t.Fatalf("SetCharacterSet: %v", err)
+	}
+	got, err := r.ReadString(uint32(len(encoded)))
+	if err != nil {
+		t.Fatalf("ReadString: %v", err)
+	}
+	if got != want {
+		t.Errorf("ReadString() = %q, want %q", got, want)
+	}
+}
+
+func TestMatchEscapeSequence_PrefersLongestMatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         []byte
+		wantCharset  string
+		wantConsumed int
+	}{
+		{name: "single byte ASCII", data: []byte("(B rest"), wantCharset: "ISO_IR 6", wantConsumed: 2},
+		{name: "three byte JIS X 0212", data: []byte("$(Drest"), wantCharset: "ISO 2022 IR 159", wantConsumed: 3},
+		{name: "unknown sequence", data: []byte("!Zrest"), wantCharset: "", wantConsumed: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, consumed := matchEscapeSequence(tt.data)
+			if d.charset != tt.wantCharset || consumed != tt.wantConsumed {
+				t.Errorf("matchEscapeSequence(%q) = (%q, %d), want (%q, %d)",
+					tt.data, d.charset, consumed, tt.wantCharset, tt.wantConsumed)
+			}
+		})
+	}
+}
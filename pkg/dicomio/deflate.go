@@ -0,0 +1,31 @@
+package dicomio
+
+import (
+	"compress/flate"
+	"errors"
+)
+
+// ErrNoOpenDeflate is returned by PopDeflate when called without a matching PushDeflate.
+var ErrNoOpenDeflate = errors.New("dicomio: PopDeflate called with no open PushDeflate")
+
+// rawReader adapts a *reader's rawRead (the still-compressed byte source) to io.Reader, so it can feed a
+// flate.Reader without going through the public Read dispatcher that PushDeflate itself installs.
+type rawReader struct {
+	r *reader
+}
+
+func (a rawReader) Read(p []byte) (int, error) { return a.r.rawRead(p) }
+
+func (r *reader) PushDeflate() error {
+	r.deflateStack = append(r.deflateStack, flate.NewReader(rawReader{r}))
+	return nil
+}
+
+func (r *reader) PopDeflate() error {
+	if len(r.deflateStack) == 0 {
+		return ErrNoOpenDeflate
+	}
+	top := r.deflateStack[len(r.deflateStack)-1]
+	r.deflateStack = r.deflateStack[:len(r.deflateStack)-1]
+	return top.Close()
+}
@@ -0,0 +1,151 @@
+package dicomio
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func deflateBytes(t *testing.T, plain []byte) []byte {
+	t.Helper()
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+	if err != nil {
+		t.Fatalf("flate.NewWriter: %v", err)
+	}
+	if _, err := fw.Write(plain); err != nil {
+		t.Fatalf("flate Write: %v", err)
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatalf("flate Close: %v", err)
+	}
+	return compressed.Bytes()
+}
+
+func TestPushPopDeflate_DecompressesWithinLimit(t *testing.T) {
+	plain := []byte("this is the decompressed DICOM element content")
+	compressed := deflateBytes(t, plain)
+	trailing := []byte{0xAA, 0xBB} // bytes belonging to the *next* element, after the compressed span
+
+	r, err := NewReader(bytes.NewReader(append(append([]byte{}, compressed...), trailing...)), binary.LittleEndian, int64(len(compressed)+len(trailing)))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if err := r.PushLimit(int64(len(compressed))); err != nil {
+		t.Fatalf("PushLimit: %v", err)
+	}
+	if err := r.PushDeflate(); err != nil {
+		t.Fatalf("PushDeflate: %v", err)
+	}
+
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll decompressed content: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Errorf("decompressed = %q, want %q", got, plain)
+	}
+
+	if err := r.PopDeflate(); err != nil {
+		t.Fatalf("PopDeflate: %v", err)
+	}
+	r.PopLimit()
+
+	rest, err := r.ReadBytes(uint32(len(trailing)))
+	if err != nil {
+		t.Fatalf("ReadBytes(trailing): %v", err)
+	}
+	if !bytes.Equal(rest, trailing) {
+		t.Errorf("bytes after PopLimit = %v, want %v", rest, trailing)
+	}
+}
+
+func TestPopDeflate_WithoutPushReturnsError(t *testing.T) {
+	r, err := NewReader(bytes.NewReader(nil), binary.LittleEndian, 0)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if err := r.PopDeflate(); !errors.Is(err, ErrNoOpenDeflate) {
+		t.Errorf("PopDeflate() = %v, want ErrNoOpenDeflate", err)
+	}
+}
+
+func TestPushDeflate_ReadsPastCompressedLimitOnCompressibleData(t *testing.T) {
+	// Regression test: readRaw and Skip used to gate on BytesLeftUntilLimit() < n, which counts compressed
+	// bytes while a PushDeflate layer is active, against n (a count of decompressed bytes). On realistically
+	// compressible data the decompressed size vastly exceeds the compressed size, so that precheck fired
+	// long before the flate.Reader actually ran out of decompressed bytes to hand back.
+	plain := bytes.Repeat([]byte{0x42}, 400)
+	compressed := deflateBytes(t, plain)
+	if len(compressed) >= len(plain) {
+		t.Fatalf("fixture isn't compressible enough: compressed=%d plain=%d", len(compressed), len(plain))
+	}
+
+	r, err := NewReader(bytes.NewReader(compressed), binary.LittleEndian, int64(len(compressed)))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if err := r.PushDeflate(); err != nil {
+		t.Fatalf("PushDeflate: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		got, err := r.ReadUInt32()
+		if err != nil {
+			t.Fatalf("ReadUInt32() #%d: %v", i, err)
+		}
+		if got != 0x42424242 {
+			t.Errorf("ReadUInt32() #%d = %#x, want 0x42424242", i, got)
+		}
+	}
+}
+
+func TestPushDeflate_ReadStringPastCompressedLimitOnCompressibleData(t *testing.T) {
+	plain := bytes.Repeat([]byte("A"), 96)
+	compressed := deflateBytes(t, plain)
+	if len(compressed) >= len(plain) {
+		t.Fatalf("fixture isn't compressible enough: compressed=%d plain=%d", len(compressed), len(plain))
+	}
+
+	r, err := NewReader(bytes.NewReader(compressed), binary.LittleEndian, int64(len(compressed)))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if err := r.PushDeflate(); err != nil {
+		t.Fatalf("PushDeflate: %v", err)
+	}
+
+	got, err := r.ReadString(uint32(len(plain)))
+	if err != nil {
+		t.Fatalf("ReadString: %v", err)
+	}
+	if got != string(plain) {
+		t.Errorf("ReadString() = %q, want %q", got, string(plain))
+	}
+}
+
+func TestPushDeflate_DoesNotUseSliceFastPathAcrossLayer(t *testing.T) {
+	// Regression test: a slice-backed Reader's ReadUInt16/32 and ReadString fast paths must not bypass an
+	// active PushDeflate layer and hand back still-compressed bytes.
+	plain := make([]byte, 4)
+	binary.LittleEndian.PutUint32(plain, 0x11223344)
+	compressed := deflateBytes(t, plain)
+
+	r, err := NewBytesReader(compressed, binary.LittleEndian, int64(len(compressed)))
+	if err != nil {
+		t.Fatalf("NewBytesReader: %v", err)
+	}
+	if err := r.PushDeflate(); err != nil {
+		t.Fatalf("PushDeflate: %v", err)
+	}
+	got, err := r.ReadUInt32()
+	if err != nil {
+		t.Fatalf("ReadUInt32: %v", err)
+	}
+	if got != 0x11223344 {
+		t.Errorf("ReadUInt32() = %#x, want 0x11223344 (decompressed, not raw compressed bytes)", got)
+	}
+}
@@ -0,0 +1,109 @@
+package dicomio
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// itemTag and sequenceDelimitationItemTag are the two tags EncapsulatedFragmentReader looks for while
+// walking an encapsulated PixelData element (see PS3.5 Annex A.4). They're defined locally since dicomio
+// has no dependency on a higher-level data-dictionary package.
+var (
+	itemTag                     = Tag{Group: 0xFFFE, Element: 0xE000}
+	sequenceDelimitationItemTag = Tag{Group: 0xFFFE, Element: 0xE0DD}
+)
+
+// EncapsulatedFragmentReader walks an encapsulated PixelData element (undefined length, one of the JPEG,
+// JPEG-LS, JPEG 2000, or RLE Lossless transfer syntaxes) fragment by fragment. Construct it once the
+// underlying Reader is positioned right after PixelData's tag, VR, and undefined length (0xFFFFFFFF); it
+// consumes the mandatory Basic Offset Table item immediately, then yields one io.Reader per subsequent
+// fragment item until the Sequence Delimitation Item is reached.
+type EncapsulatedFragmentReader struct {
+	r                Reader
+	basicOffsetTable []uint32
+	done             bool
+}
+
+// NewEncapsulatedFragmentReader reads the Basic Offset Table item (PS3.5 Annex A.4, always present,
+// possibly empty) and returns a reader ready to yield fragments via Next.
+func NewEncapsulatedFragmentReader(r Reader) (*EncapsulatedFragmentReader, error) {
+	tag, length, err := readItemHeader(r)
+	if err != nil {
+		return nil, fmt.Errorf("dicomio: reading Basic Offset Table item: %v", err)
+	}
+	if tag != itemTag {
+		return nil, fmt.Errorf("dicomio: expected Item tag %v for Basic Offset Table, got %v", itemTag, tag)
+	}
+	var offsets []uint32
+	if length > 0 {
+		if length%4 != 0 {
+			return nil, fmt.Errorf("dicomio: Basic Offset Table length %d is not a multiple of 4", length)
+		}
+		offsets = make([]uint32, length/4)
+		for i := range offsets {
+			if offsets[i], err = r.ReadUInt32(); err != nil {
+				return nil, fmt.Errorf("dicomio: reading Basic Offset Table entry %d: %v", i, err)
+			}
+		}
+	}
+	return &EncapsulatedFragmentReader{r: r, basicOffsetTable: offsets}, nil
+}
+
+// BasicOffsetTable returns the byte offset, within the concatenated decoded frame data, of each frame
+// boundary the file declared. It's empty if the file left the Basic Offset Table item empty (allowed when
+// frame boundaries coincide with fragment boundaries, or for single-frame images).
+func (e *EncapsulatedFragmentReader) BasicOffsetTable() []uint32 {
+	return e.basicOffsetTable
+}
+
+// Next returns an io.Reader over the next fragment's encoded bytes. It returns io.EOF, and no error, once
+// the Sequence Delimitation Item has been consumed; any other error indicates a malformed stream.
+func (e *EncapsulatedFragmentReader) Next() (io.Reader, error) {
+	if e.done {
+		return nil, io.EOF
+	}
+	tag, length, err := readItemHeader(e.r)
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case sequenceDelimitationItemTag:
+		e.done = true
+		if length != 0 {
+			// Not standard-conforming, but there's nothing useful left to do with trailing bytes on the
+			// terminator itself; skip them rather than failing the whole pixel data element over it.
+			if err := e.r.Skip(int64(length)); err != nil {
+				return nil, err
+			}
+		}
+		return nil, io.EOF
+	case itemTag:
+		data, err := e.r.ReadBytes(length)
+		if err != nil {
+			return nil, fmt.Errorf("dicomio: reading %d byte fragment: %v", length, err)
+		}
+		return bytes.NewReader(data), nil
+	default:
+		return nil, fmt.Errorf("dicomio: expected Item or Sequence Delimitation Item tag, got %v", tag)
+	}
+}
+
+// readItemHeader reads a (group, element) tag followed by its 4-byte length, the shape every entry in an
+// encapsulated pixel data sequence uses regardless of whether it's an Item or the Sequence Delimitation
+// Item.
+func readItemHeader(r Reader) (Tag, uint32, error) {
+	group, err := r.ReadUInt16()
+	if err != nil {
+		return Tag{}, 0, err
+	}
+	element, err := r.ReadUInt16()
+	if err != nil {
+		return Tag{}, 0, err
+	}
+	length, err := r.ReadUInt32()
+	if err != nil {
+		return Tag{}, 0, err
+	}
+	return Tag{Group: group, Element: element}, length, nil
+}
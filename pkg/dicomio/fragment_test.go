@@ -0,0 +1,120 @@
+package dicomio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// buildEncapsulatedPixelData assembles the bytes of an encapsulated PixelData value (everything after its
+// tag, VR, and the 0xFFFFFFFF undefined-length marker): a Basic Offset Table item, one Item per fragment,
+// and a Sequence Delimitation Item.
+func buildEncapsulatedPixelData(t *testing.T, offsetTable []uint32, fragments [][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writeItem := func(tag Tag, data []byte) {
+		var hdr [8]byte
+		binary.LittleEndian.PutUint16(hdr[0:2], tag.Group)
+		binary.LittleEndian.PutUint16(hdr[2:4], tag.Element)
+		binary.LittleEndian.PutUint32(hdr[4:8], uint32(len(data)))
+		buf.Write(hdr[:])
+		buf.Write(data)
+	}
+
+	var bot bytes.Buffer
+	for _, off := range offsetTable {
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], off)
+		bot.Write(b[:])
+	}
+	writeItem(itemTag, bot.Bytes())
+	for _, f := range fragments {
+		writeItem(itemTag, f)
+	}
+	writeItem(sequenceDelimitationItemTag, nil)
+	return buf.Bytes()
+}
+
+func TestEncapsulatedFragmentReader_IteratesFragmentsAndStops(t *testing.T) {
+	fragments := [][]byte{[]byte("fragment one"), []byte("fragment two"), []byte("fragment three")}
+	data := buildEncapsulatedPixelData(t, []uint32{0, 12}, fragments)
+
+	r, err := NewBytesReader(data, binary.LittleEndian, int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewBytesReader: %v", err)
+	}
+	fr, err := NewEncapsulatedFragmentReader(r)
+	if err != nil {
+		t.Fatalf("NewEncapsulatedFragmentReader: %v", err)
+	}
+	if got, want := fr.BasicOffsetTable(), []uint32{0, 12}; !equalUint32(got, want) {
+		t.Errorf("BasicOffsetTable() = %v, want %v", got, want)
+	}
+
+	var got [][]byte
+	for {
+		fragReader, err := fr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		b, err := ioutil.ReadAll(fragReader)
+		if err != nil {
+			t.Fatalf("ReadAll fragment: %v", err)
+		}
+		got = append(got, b)
+	}
+
+	if len(got) != len(fragments) {
+		t.Fatalf("got %d fragments, want %d", len(got), len(fragments))
+	}
+	for i, want := range fragments {
+		if !bytes.Equal(got[i], want) {
+			t.Errorf("fragment %d = %q, want %q", i, got[i], want)
+		}
+	}
+
+	// Calling Next again after the delimiter keeps returning io.EOF rather than erroring.
+	if _, err := fr.Next(); err != io.EOF {
+		t.Errorf("Next() after delimiter = %v, want io.EOF", err)
+	}
+}
+
+func TestEncapsulatedFragmentReader_EmptyBasicOffsetTable(t *testing.T) {
+	data := buildEncapsulatedPixelData(t, nil, [][]byte{[]byte("only fragment")})
+	r, err := NewBytesReader(data, binary.LittleEndian, int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewBytesReader: %v", err)
+	}
+	fr, err := NewEncapsulatedFragmentReader(r)
+	if err != nil {
+		t.Fatalf("NewEncapsulatedFragmentReader: %v", err)
+	}
+	if len(fr.BasicOffsetTable()) != 0 {
+		t.Errorf("BasicOffsetTable() = %v, want empty", fr.BasicOffsetTable())
+	}
+	fragReader, err := fr.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	b, _ := ioutil.ReadAll(fragReader)
+	if string(b) != "only fragment" {
+		t.Errorf("fragment = %q, want %q", b, "only fragment")
+	}
+}
+
+func equalUint32(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
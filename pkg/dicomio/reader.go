@@ -1,15 +1,25 @@
 package dicomio
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"strings"
 )
 
 var (
 	ErrorInsufficientBytesLeft = errors.New("not enough bytes left until buffer limit to complete this operation")
+	// ErrLimitExceeded is returned by PushLimit when n is negative or the new limit would overflow an int64
+	// or reach past the limit already in effect, by Skip when n is negative, and by the read methods if
+	// adding to the internal byte count would overflow an int64. It is distinct from io.EOF, which Read
+	// returns once the current limit is exhausted, and from ErrorInsufficientBytesLeft, which Skip, ReadBytes,
+	// ReadString and ReadUInt16/32/Int16/32 return when asked for more bytes than are left before the limit.
+	// A Read whose buffer reaches past the limit does not get ErrLimitExceeded: it returns only the bytes up
+	// to the limit.
+	ErrLimitExceeded = errors.New("read would exceed the current dicomio limit")
 )
 
 // Reader provides common functionality for reading underlying DICOM data.
@@ -23,12 +33,32 @@ type Reader interface {
 	ReadInt16() (int16, error)
 	// ReadInt32 reads a int32 from the underlying reader
 	ReadInt32() (int32, error)
-	// ReadString reads an n byte string from the underlying reader
+	// ReadString reads an n byte string from the underlying reader, decoding it per the character set
+	// configured via SetCharacterSet (ISO_IR 6 / ASCII if none was set).
 	ReadString(n uint32) (string, error)
-	// Skip skips the reader ahead by n bytes
+	// ReadBytes reads n raw bytes, performing no string decoding. When the Reader was constructed with
+	// NewBytesReader and no PushDeflate layer is open, the returned slice aliases the backing array and is
+	// only valid until the next call that advances the reader (Read, Skip, ReadString, ReadBytes, ...);
+	// callers parsing OB/OW/UN VRs that need to retain the data past that point must copy it. For a
+	// stream-backed Reader, or while a PushDeflate layer is open, the slice is freshly allocated and safe to
+	// keep.
+	ReadBytes(n uint32) ([]byte, error)
+	// SetCharacterSet configures how subsequent ReadString calls decode PN/LO/LT/SH/ST/UT values, from the
+	// values of the (0008,0005) SpecificCharacterSet element. values[0] is the character set used from the
+	// start of each string; the remaining values are not recorded. An ISO 2022 escape sequence within the
+	// string (recognized per PS3.5 Section 6.1.2.5) switches to the set it designates, whether or not that
+	// set is named in values.
+	SetCharacterSet(values []string) error
+	// Skip skips the reader ahead by n bytes. It returns ErrorInsufficientBytesLeft if n is larger than
+	// BytesLeftUntilLimit, and ErrLimitExceeded if n is negative or would overflow the internal bytesRead
+	// accounting. While a PushDeflate layer is open, n counts decompressed bytes and is not compared with
+	// BytesLeftUntilLimit; running out of data then returns io.EOF.
 	Skip(n int64) error
 	// PushLimit sets a read limit of n bytes from the current position of the reader. Once the limit is reached,
-	// IsLimitExhausted will return true, and other attempts to read data from dicomio.Reader will return io.EOF.
+	// IsLimitExhausted will return true, Read will return io.EOF, and the other read methods will return
+	// ErrorInsufficientBytesLeft. A Read whose buffer reaches past the limit returns only the bytes up to
+	// the limit. PushLimit itself returns ErrLimitExceeded if n is negative, or if computing the new
+	// limit would overflow an int64 or exceed the limit already in effect.
 	PushLimit(n int64) error
 	// PopLimit removes the most recent limit set, and restores the limit before that one.
 	PopLimit()
@@ -36,6 +66,17 @@ type Reader interface {
 	IsLimitExhausted() bool
 	// BytesLeftUntilLimit returns the number of bytes remaining until we reach the currently set limit posiiton.
 	BytesLeftUntilLimit() int64
+	// PushDeflate splices a DEFLATE decompressor (as used by the Deflated Explicit VR Little Endian
+	// transfer syntax, 1.2.840.10008.1.2.1.99) in front of the Reader: subsequent Read/ReadUInt16/32/
+	// ReadInt16/32/ReadString/ReadBytes calls return decompressed bytes, while BytesLeftUntilLimit and the
+	// limit pushed with PushLimit keep tracking the compressed length actually consumed from the source.
+	// Callers should PushLimit the compressed element's length before calling PushDeflate, and PopDeflate
+	// before PopLimit.
+	PushDeflate() error
+	// PopDeflate tears down the most recently pushed PushDeflate layer, returning subsequent reads to the
+	// compressed byte stream. It returns an error if there's no open PushDeflate layer, or if closing the
+	// underlying flate.Reader fails (e.g. a truncated DEFLATE stream).
+	PopDeflate() error
 }
 
 type reader struct {
@@ -44,9 +85,37 @@ type reader struct {
 	limit      int64
 	bytesRead  int64
 	limitStack []int64
+	// charset is the Defined Term (e.g. "ISO_IR 100") used to decode the start of each string read by
+	// ReadString. Empty means ISO_IR 6 / ASCII, the DICOM default repertoire.
+	charset string
+	// buf is the backing array for a slice-based Reader (see NewBytesReader). When non-nil, Read,
+	// ReadUInt16/32, ReadInt16/32, ReadString and ReadBytes all work directly off buf[bytesRead:] instead of
+	// going through in, avoiding the per-call allocation and io.ReadFull of the stream path.
+	buf []byte
+	// deflateStack holds the active PushDeflate layers, innermost last. While non-empty, Read is served by
+	// the top layer's flate.Reader instead of rawRead, so bytesRead/limit keep tracking the *compressed*
+	// byte count pulled from the underlying source (see deflate.go).
+	deflateStack []io.ReadCloser
+}
+
+// sliceBacked reports whether this Reader can use its buf fast path right now: buf must be set, and no
+// PushDeflate layer can be active, since the bytes in buf beyond the current position are still
+// compressed and can't be decoded or sliced directly.
+func (r *reader) sliceBacked() bool {
+	return r.buf != nil && len(r.deflateStack) == 0
 }
 
 func NewReader(in io.Reader, bo binary.ByteOrder, limit int64) (Reader, error) {
+	// A *bytes.Reader already holds its data in a single contiguous array; read it out once up front so
+	// the per-call fast paths below can slice directly into it instead of bouncing every ReadUInt16/32,
+	// ReadInt16/32, and ReadString call through a freshly allocated io.ReadFull.
+	if br, ok := in.(*bytes.Reader); ok {
+		buf := make([]byte, br.Len())
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, err
+		}
+		return &reader{bo: bo, limit: limit, buf: buf}, nil
+	}
 	return &reader{
 		in:        in,
 		bo:        bo,
@@ -55,11 +124,77 @@ func NewReader(in io.Reader, bo binary.ByteOrder, limit int64) (Reader, error) {
 	}, nil
 }
 
+// NewBytesReader constructs a Reader backed directly by data, with no intervening copy: ReadString and
+// ReadBytes return sub-slices of data itself, and ReadUInt16/32 and ReadInt16/32 decode straight out of it
+// without allocating or going through binary.Read. Use this instead of NewReader(bytes.NewReader(data), ...)
+// when data is already fully in memory (e.g. a file read whole) to skip NewReader's own defensive copy.
+func NewBytesReader(data []byte, bo binary.ByteOrder, limit int64) (Reader, error) {
+	return &reader{bo: bo, limit: limit, buf: data}, nil
+}
+
+// SetCharacterSet configures the character set used to decode subsequent ReadString calls. See the
+// SetCharacterSet docs on the Reader interface.
+func (r *reader) SetCharacterSet(values []string) error {
+	if len(values) == 0 {
+		r.charset = ""
+		return nil
+	}
+	if _, ok := charsetRegistry[values[0]]; !ok {
+		return fmt.Errorf("dicomio: unsupported SpecificCharacterSet %q", values[0])
+	}
+	r.charset = values[0]
+	return nil
+}
+
 func (r *reader) BytesLeftUntilLimit() int64 {
 	return r.limit - r.bytesRead
 }
 
+// addBytesRead adds n to r.bytesRead, rejecting negative values and overflow so that a crafted element
+// length (e.g. math.MaxInt64, or 0xFFFFFFFF cast up from a uint32) can't wrap bytesRead around to a small
+// or negative number and defeat the limit check in BytesLeftUntilLimit.
+func (r *reader) addBytesRead(n int64) error {
+	if n < 0 {
+		return ErrLimitExceeded
+	}
+	sum := r.bytesRead + n
+	if sum < r.bytesRead {
+		return ErrLimitExceeded
+	}
+	r.bytesRead = sum
+	return nil
+}
+
+// setLimit computes bytesRead+n as the new limit, rejecting negative or overflowing n and any new limit
+// that would exceed the limit already in effect.
+func (r *reader) setLimit(n int64) (int64, error) {
+	if n < 0 {
+		return 0, ErrLimitExceeded
+	}
+	newLimit := r.bytesRead + n
+	if newLimit < r.bytesRead {
+		return 0, ErrLimitExceeded
+	}
+	if newLimit > r.limit {
+		return 0, ErrLimitExceeded
+	}
+	return newLimit, nil
+}
+
 func (r *reader) Read(p []byte) (int, error) {
+	if len(r.deflateStack) > 0 {
+		return r.deflateStack[len(r.deflateStack)-1].Read(p)
+	}
+	return r.rawRead(p)
+}
+
+// rawRead is Read's implementation against the underlying, still-compressed-if-applicable byte source; it
+// alone updates bytesRead and checks it against limit. PushDeflate's flate.Reader is fed from a wrapper
+// that calls this directly (see deflate.go), so that decompressed bytes delivered through the public Read
+// above are never double-counted against the limit. Unlike the other buf fast paths, this one uses buf
+// whenever it's set, deflate or not: buf always holds the still-compressed bytes at this layer, so slicing
+// it directly here is exactly as correct as reading it from an underlying stream would be.
+func (r *reader) rawRead(p []byte) (int, error) {
 	// Check if we've hit the limit
 	if r.BytesLeftUntilLimit() <= 0 {
 		if len(p) == 0 {
@@ -69,52 +204,139 @@ func (r *reader) Read(p []byte) (int, error) {
 	}
 
 	// If asking for more than we have left, just return whatever we've got left
-	// TODO: return a special kind of error if this situation occurs to inform the caller
 	if int64(len(p)) > r.BytesLeftUntilLimit() {
 		p = p[:r.BytesLeftUntilLimit()]
 	}
+
+	if r.buf != nil {
+		n := copy(p, r.buf[r.bytesRead:])
+		if addErr := r.addBytesRead(int64(n)); addErr != nil {
+			return n, addErr
+		}
+		if n == 0 && len(p) > 0 {
+			return 0, io.EOF
+		}
+		return n, nil
+	}
+
 	n, err := r.in.Read(p)
 	if n >= 0 {
-		r.bytesRead += int64(n)
+		if addErr := r.addBytesRead(int64(n)); addErr != nil {
+			return n, addErr
+		}
 	}
 	return n, err
 }
 
+// readRaw returns the next n bytes with no decoding. When sliceBacked() holds, it's a view into buf (no
+// copy or allocation); otherwise it's a freshly allocated copy read through Read.
+func (r *reader) readRaw(n int64) ([]byte, error) {
+	if n < 0 {
+		return nil, ErrLimitExceeded
+	}
+	// BytesLeftUntilLimit counts compressed bytes while a PushDeflate layer is active (see the field doc
+	// on deflateStack), but n here is a count of decompressed bytes, so the two aren't comparable and this
+	// precheck must be skipped; io.ReadFull below surfaces the real io.EOF/io.ErrUnexpectedEOF once the
+	// flate.Reader actually runs dry.
+	if len(r.deflateStack) == 0 && r.BytesLeftUntilLimit() < n {
+		return nil, ErrorInsufficientBytesLeft
+	}
+	if r.sliceBacked() {
+		start := r.bytesRead
+		end := start + n
+		if end > int64(len(r.buf)) {
+			return nil, ErrorInsufficientBytesLeft
+		}
+		if err := r.addBytesRead(n); err != nil {
+			return nil, err
+		}
+		return r.buf[start:end], nil
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (r *reader) ReadBytes(n uint32) ([]byte, error) {
+	return r.readRaw(int64(n))
+}
+
+// ReadUInt16, ReadUInt32, ReadInt16, and ReadInt32 all go through readRaw rather than binary.Read so that
+// hitting a PushLimit boundary mid-read is reported as ErrorInsufficientBytesLeft, consistent with
+// Skip/ReadBytes/ReadString, instead of binary.Read's io.ReadFull surfacing a bare io.ErrUnexpectedEOF (or
+// io.EOF) once rawRead's own truncate-to-the-limit behavior runs out of room.
+
 func (r *reader) ReadUInt16() (uint16, error) {
-	var out uint16
-	err := binary.Read(r, r.bo, &out)
-	return out, err
+	b, err := r.readRaw(2)
+	if err != nil {
+		return 0, err
+	}
+	return r.bo.Uint16(b), nil
 }
 
 func (r *reader) ReadUInt32() (uint32, error) {
-	var out uint32
-	err := binary.Read(r, r.bo, &out)
-	return out, err
+	b, err := r.readRaw(4)
+	if err != nil {
+		return 0, err
+	}
+	return r.bo.Uint32(b), nil
 }
 
 func (r *reader) ReadInt16() (int16, error) {
-	var out int16
-	err := binary.Read(r, r.bo, &out)
-	return out, err
+	b, err := r.readRaw(2)
+	if err != nil {
+		return 0, err
+	}
+	return int16(r.bo.Uint16(b)), nil
 }
 
 func (r *reader) ReadInt32() (int32, error) {
-	var out int32
-	err := binary.Read(r, r.bo, &out)
-	return out, err
+	b, err := r.readRaw(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(r.bo.Uint32(b)), nil
 }
+
 func (r *reader) ReadString(n uint32) (string, error) {
-	data := make([]byte, n)
-	_, err := io.ReadFull(r, data)
-	// TODO: add support for different coding systems
-	return string(data), err
+	data, err := r.readRaw(int64(n))
+	if err != nil {
+		return "", err
+	}
+	// PN values have up to three "="-separated components (alphabetic, ideographic, phonetic), each of
+	// which restarts from the configured default character set; decode them independently so an escape
+	// sequence in one component doesn't leak into the next.
+	components := bytes.Split(data, []byte("="))
+	decoded := make([]string, len(components))
+	for i, c := range components {
+		s, err := decodeWithEscapes(c, r.charset)
+		if err != nil {
+			return "", err
+		}
+		decoded[i] = s
+	}
+	return strings.Join(decoded, "="), nil
 }
 func (r *reader) Skip(n int64) error {
-	if r.BytesLeftUntilLimit() < n {
+	if n < 0 {
+		return ErrLimitExceeded
+	}
+	// As in readRaw, BytesLeftUntilLimit is counted in compressed bytes while a PushDeflate layer is
+	// active, so it can't be compared against n (a count of decompressed bytes); skip the precheck and let
+	// io.CopyN below surface the real error once the flate.Reader runs dry.
+	if len(r.deflateStack) == 0 && r.BytesLeftUntilLimit() < n {
 		// not enough left to skip
 		return ErrorInsufficientBytesLeft
 	}
 
+	if r.sliceBacked() {
+		// Just advance bytesRead; there's nothing to copy anywhere.
+		_, err := r.readRaw(n)
+		return err
+	}
+
 	_, err := io.CopyN(ioutil.Discard, r, n)
 
 	return err
@@ -122,9 +344,9 @@ func (r *reader) Skip(n int64) error {
 
 // PushLimit creates a limit n bytes from the current position
 func (r *reader) PushLimit(n int64) error {
-	newLimit := r.bytesRead + n
-	if newLimit > r.limit {
-		return fmt.Errorf("new limit exceeds current limit of buffer, new limit: %d, limit: %d", newLimit, r.limit)
+	newLimit, err := r.setLimit(n)
+	if err != nil {
+		return err
 	}
 
 	// Add current limit to the stack
@@ -0,0 +1,67 @@
+package dicomio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// makeBenchData returns a buffer of elements uint32s, interleaved with short strings, in the shape ReadUInt32
+// and ReadString calls typically see while walking a data set.
+func makeBenchData(elements int) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < elements; i++ {
+		var word [4]byte
+		binary.LittleEndian.PutUint32(word[:], uint32(i))
+		buf.Write(word[:])
+		buf.WriteString("ABCDEFGH")
+	}
+	return buf.Bytes()
+}
+
+func BenchmarkReadUInt32_Stream(b *testing.B) {
+	data := makeBenchData(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r, err := NewReader(&onlyReader{bytes.NewReader(data)}, binary.LittleEndian, int64(len(data)))
+		if err != nil {
+			b.Fatalf("NewReader: %v", err)
+		}
+		for j := 0; j < 1000; j++ {
+			if _, err := r.ReadUInt32(); err != nil {
+				b.Fatalf("ReadUInt32: %v", err)
+			}
+			if _, err := r.ReadString(8); err != nil {
+				b.Fatalf("ReadString: %v", err)
+			}
+		}
+	}
+}
+
+func BenchmarkReadUInt32_SliceBacked(b *testing.B) {
+	data := makeBenchData(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r, err := NewBytesReader(data, binary.LittleEndian, int64(len(data)))
+		if err != nil {
+			b.Fatalf("NewBytesReader: %v", err)
+		}
+		for j := 0; j < 1000; j++ {
+			if _, err := r.ReadUInt32(); err != nil {
+				b.Fatalf("ReadUInt32: %v", err)
+			}
+			if _, err := r.ReadString(8); err != nil {
+				b.Fatalf("ReadString: %v", err)
+			}
+		}
+	}
+}
+
+// onlyReader hides the *bytes.Reader type from NewReader so BenchmarkReadUInt32_Stream exercises the
+// stream path (io.ReadFull into a fresh slice per call) instead of NewReader's own *bytes.Reader
+// fast-path detection.
+type onlyReader struct {
+	r *bytes.Reader
+}
+
+func (o *onlyReader) Read(p []byte) (int, error) { return o.r.Read(p) }
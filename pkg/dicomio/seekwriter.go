@@ -0,0 +1,168 @@
+package dicomio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// seekPlaceholder tracks one open PushLengthPlaceholder on a seekWriter: where its reserved length field
+// starts, how wide it is, and where its contents started so the byte count written since can be computed
+// without bouncing through the stream.
+type seekPlaceholder struct {
+	fieldOffset   int64
+	width         int
+	contentOffset int64
+}
+
+// seekWriter is the single-pass Writer backend used when NewWriter is given an io.Writer that's also an
+// io.Seeker (e.g. *os.File): it writes every byte to out exactly once, reserving zeroed length fields in
+// place and, on Pop, seeking back to fill them in with the now-known content length before seeking forward
+// to resume writing.
+type seekWriter struct {
+	bo      binary.ByteOrder
+	charset string
+	out     io.WriteSeeker
+	pos     int64
+	stack   []seekPlaceholder
+}
+
+func (w *seekWriter) Write(p []byte) (int, error) {
+	n, err := w.out.Write(p)
+	w.pos += int64(n)
+	return n, err
+}
+
+func (w *seekWriter) writeUint(v uint64, width int) error {
+	var b [4]byte
+	switch width {
+	case 2:
+		w.bo.PutUint16(b[:2], uint16(v))
+		_, err := w.Write(b[:2])
+		return err
+	case 4:
+		w.bo.PutUint32(b[:4], uint32(v))
+		_, err := w.Write(b[:4])
+		return err
+	default:
+		return fmt.Errorf("dicomio: invalid length-field width %d, want 2 or 4", width)
+	}
+}
+
+func (w *seekWriter) WriteUInt16(v uint16) error { return w.writeUint(uint64(v), 2) }
+func (w *seekWriter) WriteUInt32(v uint32) error { return w.writeUint(uint64(v), 4) }
+func (w *seekWriter) WriteInt16(v int16) error   { return w.writeUint(uint64(uint16(v)), 2) }
+func (w *seekWriter) WriteInt32(v int32) error   { return w.writeUint(uint64(uint32(v)), 4) }
+
+func (w *seekWriter) WriteBytes(b []byte) error {
+	_, err := w.Write(b)
+	return err
+}
+
+func (w *seekWriter) WriteTag(t Tag) error {
+	if err := w.WriteUInt16(t.Group); err != nil {
+		return err
+	}
+	return w.WriteUInt16(t.Element)
+}
+
+func (w *seekWriter) SetCharacterSet(values []string) error {
+	if len(values) == 0 {
+		w.charset = ""
+		return nil
+	}
+	if _, ok := charsetEncoderRegistry[values[0]]; !ok {
+		return fmt.Errorf("dicomio: unsupported SpecificCharacterSet %q", values[0])
+	}
+	w.charset = values[0]
+	return nil
+}
+
+func (w *seekWriter) WriteString(s string) error {
+	b, err := encoderFor(w.charset).Encode(s)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (w *seekWriter) PushLengthPlaceholder(width int) error {
+	if width != 2 && width != 4 {
+		return fmt.Errorf("dicomio: invalid length-field width %d, want 2 or 4", width)
+	}
+	fieldOffset := w.pos
+	// Reserve the field with zeros; PopLengthPlaceholder seeks back and overwrites it once the content
+	// length is known. An all-zero placeholder also happens to be a valid (if misleading) length should a
+	// caller abandon the write without popping, rather than leaving stream-corrupting garbage.
+	zeros := make([]byte, width)
+	if _, err := w.Write(zeros); err != nil {
+		return err
+	}
+	w.stack = append(w.stack, seekPlaceholder{fieldOffset: fieldOffset, width: width, contentOffset: w.pos})
+	return nil
+}
+
+func (w *seekWriter) popPlaceholder() (seekPlaceholder, error) {
+	if len(w.stack) == 0 {
+		return seekPlaceholder{}, ErrNoOpenLengthPlaceholder
+	}
+	p := w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+	return p, nil
+}
+
+func (w *seekWriter) PopLengthPlaceholder() error {
+	p, err := w.popPlaceholder()
+	if err != nil {
+		return err
+	}
+	length := uint64(w.pos - p.contentOffset)
+	if length > maxForWidth(p.width) {
+		// The content is already written to out at this point (this backend streams single-pass, so there's
+		// no buffered copy to discard), so the stream is left with a stale zeroed placeholder rather than a
+		// wrong length; the caller still gets a hard error instead of a silently truncated, desynced length.
+		return ErrLengthFieldOverflow
+	}
+	endPos := w.pos
+	if _, err := w.out.Seek(p.fieldOffset, io.SeekStart); err != nil {
+		return err
+	}
+	w.pos = p.fieldOffset
+	if err := w.writeUint(length, p.width); err != nil {
+		return err
+	}
+	if _, err := w.out.Seek(endPos, io.SeekStart); err != nil {
+		return err
+	}
+	w.pos = endPos
+	return nil
+}
+
+func (w *seekWriter) PopLengthPlaceholderUndefined(delimiter Tag) error {
+	// The content is already written in place (this backend never buffers); the reserved length field just
+	// needs to be rewritten as the undefined-length sentinel, then the delimitation item appended.
+	p, err := w.popPlaceholder()
+	if err != nil {
+		return err
+	}
+	if p.width != 4 {
+		return fmt.Errorf("dicomio: undefined length requires a 4-byte length field, got width %d", p.width)
+	}
+	endPos := w.pos
+	if _, err := w.out.Seek(p.fieldOffset, io.SeekStart); err != nil {
+		return err
+	}
+	w.pos = p.fieldOffset
+	if err := w.writeUint(0xFFFFFFFF, p.width); err != nil {
+		return err
+	}
+	if _, err := w.out.Seek(endPos, io.SeekStart); err != nil {
+		return err
+	}
+	w.pos = endPos
+	if err := w.WriteTag(delimiter); err != nil {
+		return err
+	}
+	return w.WriteUInt32(0)
+}
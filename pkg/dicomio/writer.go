@@ -0,0 +1,259 @@
+package dicomio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNoOpenLengthPlaceholder is returned by PopLengthPlaceholder and PopLengthPlaceholderUndefined when
+// called without a matching PushLengthPlaceholder.
+var ErrNoOpenLengthPlaceholder = errors.New("dicomio: PopLengthPlaceholder called with no open PushLengthPlaceholder")
+
+// ErrLengthFieldOverflow is returned by PopLengthPlaceholder when the number of bytes written since the
+// matching PushLengthPlaceholder doesn't fit in the reserved field's width (2 or 4 bytes); writing it
+// anyway would silently truncate the backpatched length and desync the stream for any reader.
+var ErrLengthFieldOverflow = errors.New("dicomio: content length does not fit in the reserved length field width")
+
+// maxForWidth returns the largest content length that fits in a width-byte (2 or 4) length field.
+func maxForWidth(width int) uint64 {
+	if width == 2 {
+		return 1<<16 - 1
+	}
+	return 1<<32 - 1
+}
+
+// Tag is a DICOM (group, element) pair, written by WriteTag as two consecutive uint16s in the Writer's
+// configured byte order.
+type Tag struct {
+	Group, Element uint16
+}
+
+// Writer is the write-side mirror of Reader: it encodes DICOM primitive values using a configured byte
+// order and character set, and lets callers reserve a length field before writing a sequence/item/element's
+// contents and backpatch it once the contents (and therefore the length) are known.
+type Writer interface {
+	io.Writer
+	// WriteUInt16 writes v in the Writer's configured byte order.
+	WriteUInt16(v uint16) error
+	// WriteUInt32 writes v in the Writer's configured byte order.
+	WriteUInt32(v uint32) error
+	// WriteInt16 writes v in the Writer's configured byte order.
+	WriteInt16(v int16) error
+	// WriteInt32 writes v in the Writer's configured byte order.
+	WriteInt32(v int32) error
+	// WriteString writes s, encoding it per the character set configured with SetCharacterSet.
+	WriteString(s string) error
+	// WriteBytes writes b unmodified.
+	WriteBytes(b []byte) error
+	// WriteTag writes t.Group followed by t.Element.
+	WriteTag(t Tag) error
+	// SetCharacterSet configures how subsequent WriteString calls encode PN/LO/LT/SH/ST/UT values. It
+	// mirrors Reader.SetCharacterSet and should be called with the same values once (0008,0005)
+	// SpecificCharacterSet has been decided for the file being written.
+	SetCharacterSet(values []string) error
+	// PushLengthPlaceholder reserves a width-byte (2 or 4) length field at the current position, and starts
+	// counting the bytes written after it. width must be 2 or 4; any other value is an error.
+	PushLengthPlaceholder(width int) error
+	// PopLengthPlaceholder backpatches the most recently pushed placeholder with the number of bytes
+	// written since the matching PushLengthPlaceholder, and removes it from the placeholder stack. It
+	// returns ErrNoOpenLengthPlaceholder if there's no matching PushLengthPlaceholder, and
+	// ErrLengthFieldOverflow if that many bytes don't fit in the placeholder's reserved width.
+	PopLengthPlaceholder() error
+	// PopLengthPlaceholderUndefined closes the most recently pushed placeholder as an undefined-length
+	// element: instead of backpatching the real content length, it writes the DICOM undefined-length
+	// sentinel (0xFFFFFFFF) into the reserved field, then the contents, then delimiter as a delimitation
+	// item (delimiter, followed by a 4-byte length of 0), per PS3.5 Section 7.5. The reserved field's width
+	// must be 4; it returns ErrNoOpenLengthPlaceholder if there's no matching PushLengthPlaceholder.
+	PopLengthPlaceholderUndefined(delimiter Tag) error
+}
+
+// frame is one level of a writer's buffering stack: either the root (depth 0, flushed straight through to
+// an underlying io.Writer if one was given) or the pending contents of an open PushLengthPlaceholder.
+type frame struct {
+	buf   bytes.Buffer
+	width int // length-field width in bytes; unused (0) for the root frame
+}
+
+// writer is an in-memory, two-pass Writer: every Write call lands in the innermost open frame, and
+// PushLengthPlaceholder/PopLengthPlaceholder move bytes between frames once a length becomes known. This
+// backend is used both for NewBufferWriter (out == nil, the caller reads Bytes() when done) and for
+// NewWriter over a plain, non-seekable io.Writer (out != nil, and the root frame's buf is flushed to out on
+// every write once no placeholder is open, giving true single-pass streaming except across an open span).
+type writer struct {
+	bo      binary.ByteOrder
+	charset string
+	out     io.Writer
+	frames  []*frame
+}
+
+// NewBufferWriter constructs a Writer that builds its output entirely in memory. Call Bytes once writing
+// is complete to retrieve the encoded data; this is the two-pass mode, useful for building an
+// explicit-length element/sequence before its final size is known.
+func NewBufferWriter(bo binary.ByteOrder) Writer {
+	return &writer{bo: bo, frames: []*frame{{}}}
+}
+
+// NewWriter constructs a Writer that encodes directly to out. If out also implements io.Seeker, length
+// placeholders are backpatched in place with Seek, so bytes are written to out exactly once each
+// (single-pass). Otherwise, spans between a PushLengthPlaceholder and its Pop are buffered in memory (since
+// their length can't be known until they're fully written) and flushed to out as a unit on Pop; writes
+// outside any open placeholder still go straight to out.
+func NewWriter(out io.Writer, bo binary.ByteOrder) (Writer, error) {
+	if out == nil {
+		return nil, errors.New("dicomio: NewWriter requires a non-nil io.Writer")
+	}
+	if seeker, ok := out.(io.WriteSeeker); ok {
+		return &seekWriter{bo: bo, out: seeker}, nil
+	}
+	return &writer{bo: bo, out: out, frames: []*frame{{}}}, nil
+}
+
+// Bytes returns everything written to the root frame so far. For a NewBufferWriter, this is the complete
+// encoded output once writing is finished (and there must be no open placeholders left). For a NewWriter
+// over a non-seekable io.Writer, the root frame is flushed to out as it's written, so Bytes returns nil.
+func (w *writer) Bytes() []byte {
+	if w.out != nil {
+		return nil
+	}
+	return w.frames[0].buf.Bytes()
+}
+
+func (w *writer) top() *frame { return w.frames[len(w.frames)-1] }
+
+func (w *writer) Write(p []byte) (int, error) {
+	n, err := w.top().buf.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if err := w.flushRootIfStreaming(); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// flushRootIfStreaming writes out the root frame's buffered bytes to out (if this Writer is streaming and
+// no placeholder is currently open) so memory use doesn't grow across a long run of top-level writes.
+func (w *writer) flushRootIfStreaming() error {
+	if w.out == nil || len(w.frames) != 1 {
+		return nil
+	}
+	root := w.frames[0]
+	if root.buf.Len() == 0 {
+		return nil
+	}
+	// A failed or short write to out returns its error to the caller, and the bytes out did not accept
+	// stay buffered for the next flush.
+	written, err := w.out.Write(root.buf.Bytes())
+	root.buf.Next(written)
+	return err
+}
+
+func (w *writer) writeUint(v uint64, width int) error {
+	var b [4]byte
+	switch width {
+	case 2:
+		w.bo.PutUint16(b[:2], uint16(v))
+		_, err := w.Write(b[:2])
+		return err
+	case 4:
+		w.bo.PutUint32(b[:4], uint32(v))
+		_, err := w.Write(b[:4])
+		return err
+	default:
+		return fmt.Errorf("dicomio: invalid length-field width %d, want 2 or 4", width)
+	}
+}
+
+func (w *writer) WriteUInt16(v uint16) error { return w.writeUint(uint64(v), 2) }
+func (w *writer) WriteUInt32(v uint32) error { return w.writeUint(uint64(v), 4) }
+func (w *writer) WriteInt16(v int16) error   { return w.writeUint(uint64(uint16(v)), 2) }
+func (w *writer) WriteInt32(v int32) error   { return w.writeUint(uint64(uint32(v)), 4) }
+
+func (w *writer) WriteBytes(b []byte) error {
+	_, err := w.Write(b)
+	return err
+}
+
+func (w *writer) WriteTag(t Tag) error {
+	if err := w.WriteUInt16(t.Group); err != nil {
+		return err
+	}
+	return w.WriteUInt16(t.Element)
+}
+
+func (w *writer) SetCharacterSet(values []string) error {
+	if len(values) == 0 {
+		w.charset = ""
+		return nil
+	}
+	if _, ok := charsetEncoderRegistry[values[0]]; !ok {
+		return fmt.Errorf("dicomio: unsupported SpecificCharacterSet %q", values[0])
+	}
+	w.charset = values[0]
+	return nil
+}
+
+func (w *writer) WriteString(s string) error {
+	b, err := encoderFor(w.charset).Encode(s)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (w *writer) PushLengthPlaceholder(width int) error {
+	if width != 2 && width != 4 {
+		return fmt.Errorf("dicomio: invalid length-field width %d, want 2 or 4", width)
+	}
+	w.frames = append(w.frames, &frame{width: width})
+	return nil
+}
+
+func (w *writer) popFrame() (*frame, error) {
+	if len(w.frames) < 2 {
+		return nil, ErrNoOpenLengthPlaceholder
+	}
+	f := w.frames[len(w.frames)-1]
+	w.frames = w.frames[:len(w.frames)-1]
+	return f, nil
+}
+
+func (w *writer) PopLengthPlaceholder() error {
+	f, err := w.popFrame()
+	if err != nil {
+		return err
+	}
+	length := uint64(f.buf.Len())
+	if length > maxForWidth(f.width) {
+		return ErrLengthFieldOverflow
+	}
+	if err := w.writeUint(length, f.width); err != nil {
+		return err
+	}
+	_, err = w.Write(f.buf.Bytes())
+	return err
+}
+
+func (w *writer) PopLengthPlaceholderUndefined(delimiter Tag) error {
+	f, err := w.popFrame()
+	if err != nil {
+		return err
+	}
+	if f.width != 4 {
+		return fmt.Errorf("dicomio: undefined length requires a 4-byte length field, got width %d", f.width)
+	}
+	if err := w.writeUint(0xFFFFFFFF, f.width); err != nil {
+		return err
+	}
+	if _, err := w.Write(f.buf.Bytes()); err != nil {
+		return err
+	}
+	if err := w.WriteTag(delimiter); err != nil {
+		return err
+	}
+	return w.WriteUInt32(0)
+}
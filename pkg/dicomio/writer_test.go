@@ -0,0 +1,212 @@
+package dicomio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+// memWriteSeeker is a minimal io.WriteSeeker over an in-memory buffer, standing in for an *os.File in
+// tests of the seek-based backpatching path.
+type memWriteSeeker struct {
+	data []byte
+	pos  int64
+}
+
+func (m *memWriteSeeker) Write(p []byte) (int, error) {
+	end := m.pos + int64(len(p))
+	if end > int64(len(m.data)) {
+		grown := make([]byte, end)
+		copy(grown, m.data)
+		m.data = grown
+	}
+	n := copy(m.data[m.pos:end], p)
+	m.pos = end
+	return n, nil
+}
+
+func (m *memWriteSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		m.pos = offset
+	case io.SeekCurrent:
+		m.pos += offset
+	case io.SeekEnd:
+		m.pos = int64(len(m.data)) + offset
+	}
+	return m.pos, nil
+}
+
+func newWriters(t *testing.T) (bufw Writer, seekw Writer, seek *memWriteSeeker) {
+	t.Helper()
+	bufw = NewBufferWriter(binary.BigEndian)
+	seek = &memWriteSeeker{}
+	w, err := NewWriter(seek, binary.BigEndian)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	return bufw, w, seek
+}
+
+func TestPushPopLengthPlaceholder_BackpatchesContentLength(t *testing.T) {
+	bufw, seekw, seek := newWriters(t)
+	for _, w := range []Writer{bufw, seekw} {
+		if err := w.PushLengthPlaceholder(4); err != nil {
+			t.Fatalf("PushLengthPlaceholder: %v", err)
+		}
+		if err := w.WriteBytes([]byte("hello")); err != nil {
+			t.Fatalf("WriteBytes: %v", err)
+		}
+		if err := w.PopLengthPlaceholder(); err != nil {
+			t.Fatalf("PopLengthPlaceholder: %v", err)
+		}
+	}
+
+	want := []byte{0, 0, 0, 5, 'h', 'e', 'l', 'l', 'o'}
+	if got := bufw.(*writer).Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("buffer writer = %v, want %v", got, want)
+	}
+	if !bytes.Equal(seek.data, want) {
+		t.Errorf("seek writer = %v, want %v", seek.data, want)
+	}
+}
+
+func TestPushPopLengthPlaceholder_Nested(t *testing.T) {
+	w := NewBufferWriter(binary.BigEndian)
+	if err := w.PushLengthPlaceholder(4); err != nil {
+		t.Fatalf("outer PushLengthPlaceholder: %v", err)
+	}
+	if err := w.WriteBytes([]byte("AB")); err != nil {
+		t.Fatalf("WriteBytes: %v", err)
+	}
+	if err := w.PushLengthPlaceholder(2); err != nil {
+		t.Fatalf("inner PushLengthPlaceholder: %v", err)
+	}
+	if err := w.WriteBytes([]byte("CD")); err != nil {
+		t.Fatalf("WriteBytes: %v", err)
+	}
+	if err := w.PopLengthPlaceholder(); err != nil {
+		t.Fatalf("inner PopLengthPlaceholder: %v", err)
+	}
+	if err := w.PopLengthPlaceholder(); err != nil {
+		t.Fatalf("outer PopLengthPlaceholder: %v", err)
+	}
+
+	want := []byte{0, 0, 0, 6, 'A', 'B', 0, 2, 'C', 'D'}
+	if got := w.(*writer).Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("Bytes() = %v, want %v", got, want)
+	}
+}
+
+func TestPopLengthPlaceholderUndefined_WritesDelimitationItem(t *testing.T) {
+	bufw, seekw, seek := newWriters(t)
+	delimiter := Tag{Group: 0xFFFE, Element: 0xE0DD}
+	for _, w := range []Writer{bufw, seekw} {
+		if err := w.PushLengthPlaceholder(4); err != nil {
+			t.Fatalf("PushLengthPlaceholder: %v", err)
+		}
+		if err := w.WriteBytes([]byte("AB")); err != nil {
+			t.Fatalf("WriteBytes: %v", err)
+		}
+		if err := w.PopLengthPlaceholderUndefined(delimiter); err != nil {
+			t.Fatalf("PopLengthPlaceholderUndefined: %v", err)
+		}
+	}
+
+	want := []byte{0xFF, 0xFF, 0xFF, 0xFF, 'A', 'B', 0xFF, 0xFE, 0xE0, 0xDD, 0, 0, 0, 0}
+	if got := bufw.(*writer).Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("buffer writer = %v, want %v", got, want)
+	}
+	if !bytes.Equal(seek.data, want) {
+		t.Errorf("seek writer = %v, want %v", seek.data, want)
+	}
+}
+
+func TestPopLengthPlaceholder_ContentOverflowingWidthReturnsError(t *testing.T) {
+	bufw, seekw, _ := newWriters(t)
+	content := bytes.Repeat([]byte{'x'}, 1<<16) // one byte over what a 2-byte length field can hold
+	for name, w := range map[string]Writer{"buffer": bufw, "seek": seekw} {
+		if err := w.PushLengthPlaceholder(2); err != nil {
+			t.Fatalf("%s: PushLengthPlaceholder: %v", name, err)
+		}
+		if err := w.WriteBytes(content); err != nil {
+			t.Fatalf("%s: WriteBytes: %v", name, err)
+		}
+		if err := w.PopLengthPlaceholder(); !errors.Is(err, ErrLengthFieldOverflow) {
+			t.Errorf("%s: PopLengthPlaceholder() = %v, want ErrLengthFieldOverflow", name, err)
+		}
+	}
+}
+
+func TestPopLengthPlaceholder_WithoutPushReturnsError(t *testing.T) {
+	w := NewBufferWriter(binary.LittleEndian)
+	if err := w.PopLengthPlaceholder(); !errors.Is(err, ErrNoOpenLengthPlaceholder) {
+		t.Errorf("PopLengthPlaceholder() = %v, want ErrNoOpenLengthPlaceholder", err)
+	}
+}
+
+func TestNewWriter_FlushesToNonSeekableOutOnce(t *testing.T) {
+	var out bytes.Buffer
+	w, err := NewWriter(&out, binary.BigEndian)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if err := w.WriteBytes([]byte("AB")); err != nil {
+		t.Fatalf("WriteBytes: %v", err)
+	}
+	if err := w.PushLengthPlaceholder(2); err != nil {
+		t.Fatalf("PushLengthPlaceholder: %v", err)
+	}
+	if err := w.WriteBytes([]byte("CDE")); err != nil {
+		t.Fatalf("WriteBytes: %v", err)
+	}
+	if err := w.PopLengthPlaceholder(); err != nil {
+		t.Fatalf("PopLengthPlaceholder: %v", err)
+	}
+	want := []byte{'A', 'B', 0, 3, 'C', 'D', 'E'}
+	if got := out.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("out.Bytes() = %v, want %v", got, want)
+	}
+}
+
+func TestWriteString_EncodesPerActiveCharacterSet(t *testing.T) {
+	bufw, seekw, seek := newWriters(t)
+	want := []byte{0xc4, 0xe3, 0xba, 0xc3} // GB18030 encoding of "你好"
+	for _, w := range []Writer{bufw, seekw} {
+		if err := w.SetCharacterSet([]string{"GB18030"}); err != nil {
+			t.Fatalf("SetCharacterSet: %v", err)
+		}
+		if err := w.WriteString("你好"); err != nil {
+			t.Fatalf("WriteString: %v", err)
+		}
+	}
+	if got := bufw.(*writer).Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("buffer writer = %v, want %v", got, want)
+	}
+	if !bytes.Equal(seek.data, want) {
+		t.Errorf("seek writer = %v, want %v", seek.data, want)
+	}
+}
+
+func TestWriterSetCharacterSet_RejectsUnknownValue(t *testing.T) {
+	w := NewBufferWriter(binary.LittleEndian)
+	if err := w.SetCharacterSet([]string{"NOT_A_REAL_CHARSET"}); err == nil {
+		t.Errorf("SetCharacterSet with an unregistered Defined Term = nil error, want an error")
+	}
+	if err := w.SetCharacterSet([]string{"GB18030"}); err != nil {
+		t.Errorf("SetCharacterSet(GB18030) = %v, want nil", err)
+	}
+}
+
+func TestWriteTag_WritesGroupThenElement(t *testing.T) {
+	w := NewBufferWriter(binary.LittleEndian)
+	if err := w.WriteTag(Tag{Group: 0x0008, Element: 0x0005}); err != nil {
+		t.Fatalf("WriteTag: %v", err)
+	}
+	want := []byte{0x08, 0x00, 0x05, 0x00}
+	if got := w.(*writer).Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("Bytes() = %v, want %v", got, want)
+	}
+}